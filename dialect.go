@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Dialect abstracts the SQL used to manage the 'schema_migration' table, so
+// that the runner functions (Up, Down, Status, ...) can run against more
+// than just Postgres. Built-in implementations are provided as Postgres,
+// MySQL, SQLite and MSSQL; select one explicitly with WithDialect, or leave
+// it to detectDialect to sniff the driver.
+type Dialect interface {
+	// VersionTableExists returns a query whose single result row/column
+	// holds a non-zero count if 'schema_migration' already exists.
+	VersionTableExists() string
+
+	// CreateVersionTable returns the DDL used to create the
+	// 'schema_migration' table.
+	CreateVersionTable() string
+
+	// ColumnNames returns a query for the column names currently present
+	// on 'schema_migration', used to detect a table created by an older
+	// version of this library that predates a given column.
+	ColumnNames() string
+
+	// AddColumn returns the DDL used to add the given column - one of
+	// "applied_at", "checksum" or "execution_time_ms" - to an existing
+	// 'schema_migration' table, with the type and default it would have
+	// had in CreateVersionTable.
+	AddColumn(column string) string
+
+	// InsertVersion returns the statement used to record an applied
+	// migration, with placeholders bound to (version, name, checksum,
+	// execution_time_ms).
+	InsertVersion() string
+
+	// DeleteVersion returns the statement used to remove an applied
+	// migration's record, with a placeholder bound to (version).
+	DeleteVersion() string
+
+	// LatestVersion returns a query for the highest applied version.
+	LatestVersion() string
+
+	// AppliedVersions returns a query for all applied versions, ascending.
+	AppliedVersions() string
+
+	// AppliedChecksums returns a query for the (version, checksum) of
+	// every applied migration, used to detect migrations edited after
+	// having been applied.
+	AppliedChecksums() string
+
+	// Placeholder returns the bound-parameter placeholder for the i'th
+	// (1-indexed) argument in a hand-written query.
+	Placeholder(i int) string
+
+	// LockQuery and UnlockQuery return the SQL used to acquire/release a
+	// database-level advisory lock identified by key, held for the
+	// duration of a migration run.
+	LockQuery(key int64) string
+	UnlockQuery(key int64) string
+}
+
+// detectDialect sniffs db's driver to pick a Dialect, defaulting to
+// Postgres when the driver can't be identified - migrate-go originally
+// only supported Postgres, so that remains the safe default.
+func detectDialect(db *sql.DB) Dialect {
+	return dialectForDriverType(fmt.Sprintf("%T", db.Driver()))
+}
+
+// dialectForDriverType maps a driver's type name (as reported by
+// fmt.Sprintf("%T", ...)) to the Dialect it implies. Split out from
+// detectDialect so the matching rules can be unit tested without a real
+// *sql.DB for every driver.
+func dialectForDriverType(driverType string) Dialect {
+	driverType = strings.ToLower(driverType)
+
+	switch {
+	case strings.Contains(driverType, "mysql"):
+		return MySQL
+	case strings.Contains(driverType, "sqlite"):
+		return SQLite
+	case strings.Contains(driverType, "mssql"), strings.Contains(driverType, "sqlserver"):
+		return MSSQL
+	default:
+		return Postgres
+	}
+}
@@ -0,0 +1,82 @@
+package migrate
+
+import "fmt"
+
+// MSSQL is the built-in Dialect for Microsoft SQL Server.
+var MSSQL Dialect = mssqlDialect{}
+
+type mssqlDialect struct{}
+
+func (mssqlDialect) VersionTableExists() string {
+	return `SELECT count(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_NAME = 'schema_migration';`
+}
+
+func (mssqlDialect) CreateVersionTable() string {
+	return `
+		CREATE TABLE schema_migration (
+			id INT IDENTITY(1,1) PRIMARY KEY,
+			name NVARCHAR(255) NOT NULL,
+			version BIGINT NOT NULL,
+			applied_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME(),
+			checksum NVARCHAR(64) NOT NULL DEFAULT '',
+			execution_time_ms BIGINT NOT NULL DEFAULT 0
+		)`
+}
+
+func (mssqlDialect) ColumnNames() string {
+	return `SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = 'schema_migration';`
+}
+
+func (mssqlDialect) AddColumn(column string) string {
+	switch column {
+	case "applied_at":
+		return `ALTER TABLE schema_migration ADD applied_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME()`
+	case "checksum":
+		return `ALTER TABLE schema_migration ADD checksum NVARCHAR(64) NOT NULL DEFAULT ''`
+	case "execution_time_ms":
+		return `ALTER TABLE schema_migration ADD execution_time_ms BIGINT NOT NULL DEFAULT 0`
+	default:
+		panic("migrate: unknown schema_migration column " + column)
+	}
+}
+
+func (mssqlDialect) InsertVersion() string {
+	return `INSERT INTO schema_migration (version, name, checksum, execution_time_ms) VALUES (@p1, @p2, @p3, @p4);`
+}
+
+func (mssqlDialect) DeleteVersion() string {
+	return `DELETE FROM schema_migration WHERE version = @p1`
+}
+
+func (mssqlDialect) LatestVersion() string {
+	return `SELECT TOP 1 version FROM schema_migration ORDER BY version DESC;`
+}
+
+func (mssqlDialect) AppliedVersions() string {
+	return `SELECT version FROM schema_migration ORDER BY version ASC;`
+}
+
+func (mssqlDialect) AppliedChecksums() string {
+	return `SELECT version, checksum FROM schema_migration ORDER BY version ASC;`
+}
+
+func (mssqlDialect) Placeholder(i int) string {
+	return fmt.Sprintf("@p%d", i)
+}
+
+// LockQuery captures sp_getapplock's result code explicitly and THROWs if
+// it's negative (timeout, deadlock victim, parameter error, ...): unlike
+// most T-SQL procedures, sp_getapplock reports failure through its return
+// value rather than always raising an error, so a bare EXEC would let
+// ExecContext report success even when the lock was never acquired.
+func (mssqlDialect) LockQuery(key int64) string {
+	return fmt.Sprintf(`
+		DECLARE @migrateLockResult INT;
+		EXEC @migrateLockResult = sp_getapplock @Resource = 'migrate-go:%d', @LockMode = 'Exclusive', @LockOwner = 'Session';
+		IF @migrateLockResult < 0
+			THROW 50000, 'migrate: failed acquiring migration lock', 1;`, key)
+}
+
+func (mssqlDialect) UnlockQuery(key int64) string {
+	return fmt.Sprintf("EXEC sp_releaseapplock @Resource = 'migrate-go:%d', @LockOwner = 'Session';", key)
+}
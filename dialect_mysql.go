@@ -0,0 +1,73 @@
+package migrate
+
+import "fmt"
+
+// MySQL is the built-in Dialect for MySQL/MariaDB.
+var MySQL Dialect = mysqlDialect{}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) VersionTableExists() string {
+	return `SELECT count(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migration';`
+}
+
+func (mysqlDialect) CreateVersionTable() string {
+	return `
+		CREATE TABLE schema_migration (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			version BIGINT NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			checksum VARCHAR(64) NOT NULL DEFAULT '',
+			execution_time_ms BIGINT NOT NULL DEFAULT 0
+		)`
+}
+
+func (mysqlDialect) ColumnNames() string {
+	return `SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'schema_migration';`
+}
+
+func (mysqlDialect) AddColumn(column string) string {
+	switch column {
+	case "applied_at":
+		return `ALTER TABLE schema_migration ADD COLUMN applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP`
+	case "checksum":
+		return `ALTER TABLE schema_migration ADD COLUMN checksum VARCHAR(64) NOT NULL DEFAULT ''`
+	case "execution_time_ms":
+		return `ALTER TABLE schema_migration ADD COLUMN execution_time_ms BIGINT NOT NULL DEFAULT 0`
+	default:
+		panic("migrate: unknown schema_migration column " + column)
+	}
+}
+
+func (mysqlDialect) InsertVersion() string {
+	return `INSERT INTO schema_migration (version, name, checksum, execution_time_ms) VALUES (?, ?, ?, ?);`
+}
+
+func (mysqlDialect) DeleteVersion() string {
+	return `DELETE FROM schema_migration WHERE version = ?`
+}
+
+func (mysqlDialect) LatestVersion() string {
+	return `SELECT version FROM schema_migration ORDER BY version DESC LIMIT 1;`
+}
+
+func (mysqlDialect) AppliedVersions() string {
+	return `SELECT version FROM schema_migration ORDER BY version ASC;`
+}
+
+func (mysqlDialect) AppliedChecksums() string {
+	return `SELECT version, checksum FROM schema_migration ORDER BY version ASC;`
+}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (mysqlDialect) LockQuery(key int64) string {
+	return fmt.Sprintf("SELECT GET_LOCK('migrate-go:%d', -1);", key)
+}
+
+func (mysqlDialect) UnlockQuery(key int64) string {
+	return fmt.Sprintf("SELECT RELEASE_LOCK('migrate-go:%d');", key)
+}
@@ -0,0 +1,98 @@
+package migrate
+
+import "fmt"
+
+// Postgres is the built-in Dialect for PostgreSQL. It is the default when
+// no Dialect is given and the driver can't be sniffed.
+var Postgres Dialect = postgresDialect{}
+
+type postgresDialect struct{}
+
+func (postgresDialect) VersionTableExists() string {
+	return `SELECT count(table_name) FROM information_schema.tables WHERE table_name = 'schema_migration';`
+}
+
+func (postgresDialect) CreateVersionTable() string {
+	return `
+		CREATE TABLE schema_migration (
+			id serial PRIMARY KEY,
+			name text NOT NULL,
+			version bigint NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now(),
+			checksum text NOT NULL DEFAULT '',
+			execution_time_ms bigint NOT NULL DEFAULT 0
+		)`
+}
+
+func (postgresDialect) ColumnNames() string {
+	return `SELECT column_name FROM information_schema.columns WHERE table_name = 'schema_migration';`
+}
+
+func (postgresDialect) AddColumn(column string) string {
+	switch column {
+	case "applied_at":
+		return `ALTER TABLE schema_migration ADD COLUMN applied_at timestamptz NOT NULL DEFAULT now()`
+	case "checksum":
+		return `ALTER TABLE schema_migration ADD COLUMN checksum text NOT NULL DEFAULT ''`
+	case "execution_time_ms":
+		return `ALTER TABLE schema_migration ADD COLUMN execution_time_ms bigint NOT NULL DEFAULT 0`
+	default:
+		panic("migrate: unknown schema_migration column " + column)
+	}
+}
+
+func (postgresDialect) InsertVersion() string {
+	return `
+		INSERT INTO
+			schema_migration (version, name, checksum, execution_time_ms)
+		VALUES
+		    ($1, $2, $3, $4);`
+}
+
+func (postgresDialect) DeleteVersion() string {
+	return `DELETE FROM schema_migration WHERE version = $1`
+}
+
+func (postgresDialect) LatestVersion() string {
+	return `
+		SELECT
+		    version
+		FROM
+		    schema_migration
+		ORDER BY
+		    version DESC
+		LIMIT 1;`
+}
+
+func (postgresDialect) AppliedVersions() string {
+	return `
+		SELECT
+		    version
+		FROM
+		    schema_migration
+		ORDER BY
+		    version ASC;`
+}
+
+func (postgresDialect) AppliedChecksums() string {
+	return `
+		SELECT
+		    version,
+		    checksum
+		FROM
+		    schema_migration
+		ORDER BY
+		    version ASC;`
+}
+
+func (postgresDialect) Placeholder(i int) string {
+	return fmt.Sprintf("$%d", i)
+}
+
+func (postgresDialect) LockQuery(key int64) string {
+	return fmt.Sprintf("SELECT pg_advisory_lock(%d);", key)
+}
+
+func (postgresDialect) UnlockQuery(key int64) string {
+	return fmt.Sprintf("SELECT pg_advisory_unlock(%d);", key)
+}
@@ -0,0 +1,75 @@
+package migrate
+
+// SQLite is the built-in Dialect for SQLite.
+var SQLite Dialect = sqliteDialect{}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) VersionTableExists() string {
+	return `SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migration';`
+}
+
+func (sqliteDialect) CreateVersionTable() string {
+	return `
+		CREATE TABLE schema_migration (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			checksum TEXT NOT NULL DEFAULT '',
+			execution_time_ms INTEGER NOT NULL DEFAULT 0
+		)`
+}
+
+func (sqliteDialect) ColumnNames() string {
+	return `SELECT name FROM pragma_table_info('schema_migration');`
+}
+
+func (sqliteDialect) AddColumn(column string) string {
+	switch column {
+	case "applied_at":
+		return `ALTER TABLE schema_migration ADD COLUMN applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP`
+	case "checksum":
+		return `ALTER TABLE schema_migration ADD COLUMN checksum TEXT NOT NULL DEFAULT ''`
+	case "execution_time_ms":
+		return `ALTER TABLE schema_migration ADD COLUMN execution_time_ms INTEGER NOT NULL DEFAULT 0`
+	default:
+		panic("migrate: unknown schema_migration column " + column)
+	}
+}
+
+func (sqliteDialect) InsertVersion() string {
+	return `INSERT INTO schema_migration (version, name, checksum, execution_time_ms) VALUES (?, ?, ?, ?);`
+}
+
+func (sqliteDialect) DeleteVersion() string {
+	return `DELETE FROM schema_migration WHERE version = ?`
+}
+
+func (sqliteDialect) LatestVersion() string {
+	return `SELECT version FROM schema_migration ORDER BY version DESC LIMIT 1;`
+}
+
+func (sqliteDialect) AppliedVersions() string {
+	return `SELECT version FROM schema_migration ORDER BY version ASC;`
+}
+
+func (sqliteDialect) AppliedChecksums() string {
+	return `SELECT version, checksum FROM schema_migration ORDER BY version ASC;`
+}
+
+func (sqliteDialect) Placeholder(int) string {
+	return "?"
+}
+
+// LockQuery and UnlockQuery are no-ops: SQLite has no session-level
+// advisory lock primitive. lock() special-cases this dialect instead,
+// holding a BEGIN IMMEDIATE transaction open on a pinned connection for
+// the duration of the run - see lockSQLite in lock.go.
+func (sqliteDialect) LockQuery(int64) string {
+	return ""
+}
+
+func (sqliteDialect) UnlockQuery(int64) string {
+	return ""
+}
@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+)
+
+// fakeDriver lets detectDialect's driver-type sniffing be exercised without
+// a real database connection - sql.Open only needs the driver registered
+// under some name, it never has to actually connect.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrBadConn
+}
+
+func init() {
+	sql.Register("migrate-test-fake-mysql", fakeDriver{})
+}
+
+func TestDetectDialect(t *testing.T) {
+	db, err := sql.Open("migrate-test-fake-mysql", "")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+
+	// db.Driver() reports the concrete fakeDriver type registered above,
+	// which contains neither "mysql", "sqlite", "mssql" nor "sqlserver" -
+	// detectDialect should fall back to Postgres.
+	if got := detectDialect(db); got != Postgres {
+		t.Errorf("detectDialect with an unrecognized driver = %#v, want Postgres", got)
+	}
+}
+
+func TestDetectDialectDriverNameMatching(t *testing.T) {
+	tests := []struct {
+		name       string
+		driverType string
+		want       Dialect
+	}{
+		{"mysql driver", "*mysql.MySQLDriver", MySQL},
+		{"sqlite driver", "*sqlite3.SQLiteDriver", SQLite},
+		{"mssql driver", "*mssql.Driver", MSSQL},
+		{"sqlserver driver", "*sqlserver.Driver", MSSQL},
+		{"postgres driver", "*pq.Driver", Postgres},
+		{"unrecognized driver", "*something.Else", Postgres},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dialectForDriverType(tt.driverType); got != tt.want {
+				t.Errorf("dialectForDriverType(%q) = %#v, want %#v", tt.driverType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// lockKey identifies migrate-go's cross-process advisory lock. It is an
+// arbitrary constant shared by every caller of a given dialect, not a
+// per-migrationsPath key, so two instances pointed at different
+// directories on the same database still serialize against each other -
+// this mirrors how a single schema_migration table is shared regardless of
+// directory.
+const lockKey int64 = 0x6d6967726174652d // "migrate-" as bytes
+
+// releaseTimeout bounds how long releasing the migration lock (UnlockQuery,
+// or the SQLite COMMIT/ROLLBACK) is allowed to take. It runs against
+// context.Background() rather than the caller's ctx: by the time f returns,
+// ctx may already be cancelled or past its deadline - the ordinary case for
+// a run bounded by a deadline, or any caller that cancels after a failure -
+// and releasing against an already-dead context would abandon the lock on
+// a connection that then goes back into the pool, deadlocking every
+// subsequent run against it.
+const releaseTimeout = 5 * time.Second
+
+// lock runs f while holding dialect's cross-process advisory lock, so that
+// two application instances starting simultaneously (rolling deploy, k8s
+// pods) can't race to apply the same migrations. The lock is acquired on
+// its own *sql.Conn, pinned for the duration of f, and released
+// afterwards regardless of whether f succeeds. SQLite, which has no
+// advisory lock primitive, is handed to lockSQLite instead; any other
+// dialect reporting an empty LockQuery has f run unlocked.
+func lock(ctx context.Context, db *sql.DB, o *options, f func(ctx context.Context) error) (err error) {
+	if o.dialect == SQLite {
+		return lockSQLite(ctx, db, o, f)
+	}
+
+	query := o.dialect.LockQuery(lockKey)
+	if query == "" {
+		return f(ctx)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquiring connection for migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	lockCtx := ctx
+	if o.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, o.lockTimeout)
+		defer cancel()
+	}
+
+	if _, err := conn.ExecContext(lockCtx, query); err != nil {
+		return fmt.Errorf("failed acquiring migration lock: %w", err)
+	}
+
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+		defer cancel()
+
+		if _, unlockErr := conn.ExecContext(releaseCtx, o.dialect.UnlockQuery(lockKey)); unlockErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed releasing migration lock: %w", unlockErr))
+		}
+	}()
+
+	return f(ctx)
+}
+
+// lockSQLite serializes migration runs against SQLite, which has no
+// session-level advisory lock primitive. SQLite does, however, only ever
+// allow one writer transaction against a given database file: holding a
+// BEGIN IMMEDIATE transaction open on a pinned connection for the duration
+// of f blocks every other connection from writing - including the ones f
+// itself uses via db - until it commits or rolls back, giving the same
+// mutual exclusion the other dialects get from an advisory lock.
+func lockSQLite(ctx context.Context, db *sql.DB, o *options, f func(ctx context.Context) error) (err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquiring connection for migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	lockCtx := ctx
+	if o.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, o.lockTimeout)
+		defer cancel()
+	}
+
+	if _, err := conn.ExecContext(lockCtx, "BEGIN IMMEDIATE"); err != nil {
+		return fmt.Errorf("failed acquiring migration lock: %w", err)
+	}
+
+	defer func() {
+		stmt := "COMMIT"
+		if err != nil {
+			stmt = "ROLLBACK"
+		}
+
+		releaseCtx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+		defer cancel()
+
+		if _, unlockErr := conn.ExecContext(releaseCtx, stmt); unlockErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed releasing migration lock: %w", unlockErr))
+		}
+	}()
+
+	return f(ctx)
+}
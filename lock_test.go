@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLockKeyFitsInPositiveInt64(t *testing.T) {
+	if lockKey <= 0 {
+		t.Fatalf("lockKey = %d, want a positive int64", lockKey)
+	}
+}
+
+func TestDialectLockQueriesEmbedTheKey(t *testing.T) {
+	const key int64 = 12345
+
+	dialects := []struct {
+		name    string
+		dialect Dialect
+		// locking is false for dialects (SQLite) with no advisory lock
+		// primitive, where lock() falls back to another mechanism.
+		locking bool
+	}{
+		{"Postgres", Postgres, true},
+		{"MySQL", MySQL, true},
+		{"MSSQL", MSSQL, true},
+		{"SQLite", SQLite, false},
+	}
+
+	for _, d := range dialects {
+		t.Run(d.name, func(t *testing.T) {
+			lockQuery := d.dialect.LockQuery(key)
+			unlockQuery := d.dialect.UnlockQuery(key)
+
+			if !d.locking {
+				if lockQuery != "" || unlockQuery != "" {
+					t.Fatalf("%s: LockQuery/UnlockQuery = %q/%q, want both empty", d.name, lockQuery, unlockQuery)
+				}
+				return
+			}
+
+			if lockQuery == "" {
+				t.Fatalf("%s: LockQuery is empty, want a lock statement", d.name)
+			}
+			if unlockQuery == "" {
+				t.Fatalf("%s: UnlockQuery is empty, want an unlock statement", d.name)
+			}
+
+			keyStr := strconv.FormatInt(key, 10)
+			if !strings.Contains(lockQuery, keyStr) {
+				t.Errorf("%s: LockQuery %q does not reference key %d", d.name, lockQuery, key)
+			}
+			if !strings.Contains(unlockQuery, keyStr) {
+				t.Errorf("%s: UnlockQuery %q does not reference key %d", d.name, unlockQuery, key)
+			}
+		})
+	}
+}
@@ -2,157 +2,591 @@ package migrate
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/eskrenkovic/tql"
 )
 
+// timestampPrefixLength is the length, in digits, of a goose/sql-migrate
+// style timestamp version prefix (YYYYMMDDHHMMSS).
+const timestampPrefixLength = 14
+
 type Migration struct {
 	ID         int    `db:"id"`
 	Version    int    `db:"version"`
 	Name       string `db:"name"`
 	UpScript   string
 	DownScript string
+	// Checksum is the SHA-256 hash of UpScript, recorded alongside the
+	// applied migration so a later run can detect that the file on disk
+	// was edited after it was applied.
+	Checksum string
+	// NoTransaction, when true, disables the per-migration transaction
+	// normally wrapped around applying/reverting this migration, for DDL
+	// that can't run inside one (e.g. Postgres CREATE INDEX CONCURRENTLY,
+	// most MySQL DDL). Set via a '-- +migrate NoTransaction' directive in
+	// either script.
+	NoTransaction bool
+	// UpFunc and DownFunc, set via Register, make this a programmatic Go
+	// migration instead of a SQL one. When set, they take precedence over
+	// UpScript/DownScript.
+	UpFunc   func(context.Context, *sql.Tx) error
+	DownFunc func(context.Context, *sql.Tx) error
 }
 
-func Run(ctx context.Context, db *sql.DB, migrationsPath string) error {
-	if _, err := os.Stat(migrationsPath); err != nil {
+// MigrationStatus describes a single migration found on disk relative to
+// what has been recorded in the 'schema_migration' table, as reported by
+// Status.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Run applies all pending up-migrations found in migrationsPath. It is an
+// alias for Up, kept around for backwards compatibility.
+func Run(ctx context.Context, db *sql.DB, migrationsPath string, opts ...Option) error {
+	return Up(ctx, db, migrationsPath, opts...)
+}
+
+// Up applies all pending up-migrations found in migrationsPath.
+func Up(ctx context.Context, db *sql.DB, migrationsPath string, opts ...Option) error {
+	return UpTo(ctx, db, migrationsPath, 0, opts...)
+}
+
+// UpTo applies pending up-migrations found in migrationsPath, stopping once
+// version has been applied. Migrations with a version higher than version
+// are left untouched.
+func UpTo(ctx context.Context, db *sql.DB, migrationsPath string, version int, opts ...Option) error {
+	migrations, err := loadMigrations(migrationsPath)
+	if err != nil {
 		return err
 	}
 
-	entries, err := os.ReadDir(migrationsPath)
+	o := resolveOptions(db, opts)
+	return lock(ctx, db, o, func(ctx context.Context) error {
+		return upTo(ctx, db, migrations, version, o)
+	})
+}
+
+// RunFS applies all pending up-migrations found under dir in fsys (e.g. an
+// embed.FS). It is the fs.FS equivalent of Run/Up, for applications that
+// embed their migrations into the binary instead of shipping a directory
+// alongside it.
+func RunFS(ctx context.Context, db *sql.DB, fsys fs.FS, dir string, opts ...Option) error {
+	migrations, err := loadMigrationsFS(fsys, dir)
 	if err != nil {
 		return err
 	}
 
-	if len(entries) == 0 {
+	o := resolveOptions(db, opts)
+	return lock(ctx, db, o, func(ctx context.Context) error {
+		return upTo(ctx, db, migrations, 0, o)
+	})
+}
+
+func upTo(ctx context.Context, db *sql.DB, migrations map[int]Migration, version int, o *options) error {
+	if len(migrations) == 0 {
 		return nil
 	}
 
-	migrations := make(map[int]Migration, 0)
+	if err := ensureMigrationsSchema(ctx, db, o.dialect); err != nil {
+		return err
+	}
 
-	for _, entry := range entries {
-		// Sanity checks - only root directory, needs to have a name by convention
-		// Name convention - migrationnumber.name.up.sql
-		//                   migrationnumber.name.down.sql
-		// Needs to have both up and down!
-		filePath := entry.Name()
+	if err := verifyChecksums(ctx, db, o.dialect, migrations); err != nil {
+		return err
+	}
 
-		if filepath.Ext(filePath) != ".sql" {
-			continue
+	appliedVersions, err := appliedMigrationVersions(ctx, db, o.dialect)
+	if err != nil {
+		return err
+	}
+
+	return applyMigrations(ctx, db, o, pendingMigrations(migrations, appliedVersionSet(appliedVersions), version))
+}
+
+// UpByOne applies the single next pending up-migration found in
+// migrationsPath, if any.
+func UpByOne(ctx context.Context, db *sql.DB, migrationsPath string, opts ...Option) error {
+	migrations, err := loadMigrations(migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	o := resolveOptions(db, opts)
+
+	return lock(ctx, db, o, func(ctx context.Context) error {
+		if err := ensureMigrationsSchema(ctx, db, o.dialect); err != nil {
+			return err
 		}
 
-		parts := strings.Split(filePath, ".")
-		if len(parts) != 4 {
-			// Doesn't match the naming convention.
-			continue
+		if err := verifyChecksums(ctx, db, o.dialect, migrations); err != nil {
+			return err
+		}
+
+		appliedVersions, err := appliedMigrationVersions(ctx, db, o.dialect)
+		if err != nil {
+			return err
+		}
+
+		pending := pendingMigrations(migrations, appliedVersionSet(appliedVersions), 0)
+		if len(pending) == 0 {
+			return nil
+		}
+
+		return applyMigrations(ctx, db, o, pending[:1])
+	})
+}
+
+// Down reverts the single applied migration with the highest version.
+func Down(ctx context.Context, db *sql.DB, migrationsPath string, opts ...Option) error {
+	migrations, err := loadMigrations(migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	o := resolveOptions(db, opts)
+
+	return lock(ctx, db, o, func(ctx context.Context) error {
+		if err := ensureMigrationsSchema(ctx, db, o.dialect); err != nil {
+			return err
 		}
 
-		migrationNumber, err := strconv.Atoi(parts[0])
+		if err := verifyChecksums(ctx, db, o.dialect, migrations); err != nil {
+			return err
+		}
+
+		lastAppliedVersion, err := latestAppliedVersion(ctx, db, o.dialect)
 		if err != nil {
 			return err
 		}
 
+		if lastAppliedVersion == 0 {
+			return nil
+		}
+
+		migration, ok := migrations[lastAppliedVersion]
+		if !ok {
+			return fmt.Errorf("migrate: applied migration version %d not found in '%s'", lastAppliedVersion, migrationsPath)
+		}
+
+		return revertMigrations(ctx, db, o, []Migration{migration})
+	})
+}
+
+// DownTo reverts applied migrations with a version higher than version, in
+// descending order, leaving the database at version.
+func DownTo(ctx context.Context, db *sql.DB, migrationsPath string, version int, opts ...Option) error {
+	migrations, err := loadMigrations(migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	o := resolveOptions(db, opts)
+
+	return lock(ctx, db, o, func(ctx context.Context) error {
+		if err := ensureMigrationsSchema(ctx, db, o.dialect); err != nil {
+			return err
+		}
+
+		if err := verifyChecksums(ctx, db, o.dialect, migrations); err != nil {
+			return err
+		}
+
+		appliedVersions, err := appliedMigrationVersions(ctx, db, o.dialect)
+		if err != nil {
+			return err
+		}
+
+		var toRevert []Migration
+		for _, appliedVersion := range appliedVersions {
+			if appliedVersion <= version {
+				continue
+			}
+
+			migration, ok := migrations[appliedVersion]
+			if !ok {
+				return fmt.Errorf("migrate: applied migration version %d not found in '%s'", appliedVersion, migrationsPath)
+			}
+
+			toRevert = append(toRevert, migration)
+		}
+
+		sort.Slice(toRevert, func(i, j int) bool {
+			return toRevert[i].Version < toRevert[j].Version
+		})
+
+		return revertMigrations(ctx, db, o, toRevert)
+	})
+}
+
+// Redo reverts and then reapplies the single applied migration with the
+// highest version.
+func Redo(ctx context.Context, db *sql.DB, migrationsPath string, opts ...Option) error {
+	migrations, err := loadMigrations(migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	o := resolveOptions(db, opts)
+
+	return lock(ctx, db, o, func(ctx context.Context) error {
+		if err := ensureMigrationsSchema(ctx, db, o.dialect); err != nil {
+			return err
+		}
+
+		if err := verifyChecksums(ctx, db, o.dialect, migrations); err != nil {
+			return err
+		}
+
+		lastAppliedVersion, err := latestAppliedVersion(ctx, db, o.dialect)
+		if err != nil {
+			return err
+		}
+
+		if lastAppliedVersion == 0 {
+			return nil
+		}
+
+		migration, ok := migrations[lastAppliedVersion]
+		if !ok {
+			return fmt.Errorf("migrate: applied migration version %d not found in '%s'", lastAppliedVersion, migrationsPath)
+		}
+
+		if err := revertMigrations(ctx, db, o, []Migration{migration}); err != nil {
+			return err
+		}
+
+		return applyMigrations(ctx, db, o, []Migration{migration})
+	})
+}
+
+// Reset reverts every applied migration, leaving the database empty of
+// schema_migration records.
+func Reset(ctx context.Context, db *sql.DB, migrationsPath string, opts ...Option) error {
+	return DownTo(ctx, db, migrationsPath, 0, opts...)
+}
+
+// Status reports, for every migration found in migrationsPath, whether it
+// has been applied and, if so, when. Like the runner functions, it fails
+// loudly if an already-applied migration's checksum no longer matches the
+// file on disk, rather than silently reporting it as applied.
+func Status(ctx context.Context, db *sql.DB, migrationsPath string, opts ...Option) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(migrationsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	o := resolveOptions(db, opts)
+
+	if err := ensureMigrationsSchema(ctx, db, o.dialect); err != nil {
+		return nil, err
+	}
+
+	if err := verifyChecksums(ctx, db, o.dialect, migrations); err != nil {
+		return nil, err
+	}
+
+	appliedVersions, err := appliedMigrationVersions(ctx, db, o.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedSet := appliedVersionSet(appliedVersions)
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for version, migration := range migrations {
+		status := MigrationStatus{Version: version, Name: migration.Name}
+
+		if _, ok := appliedSet[version]; ok {
+			status.Applied = true
+			status.AppliedAt = appliedAt(ctx, db, o.dialect, version)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+func appliedAt(ctx context.Context, db *sql.DB, dialect Dialect, version int) time.Time {
+	q := fmt.Sprintf("SELECT applied_at FROM schema_migration WHERE version = %s;", dialect.Placeholder(1))
+	t, err := tql.QueryFirstOrDefault[time.Time](ctx, db, time.Time{}, q, version)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func loadMigrations(migrationsPath string) (map[int]Migration, error) {
+	if _, err := os.Stat(migrationsPath); err != nil {
+		return nil, err
+	}
+
+	return loadMigrationsFS(os.DirFS(migrationsPath), ".")
+}
+
+// loadMigrationsFS reads and parses migration files out of dir in fsys. It
+// backs both the os-path loader above and RunFS, which instead walks an
+// arbitrary fs.FS (typically an embed.FS).
+func loadMigrationsFS(fsys fs.FS, dir string) (map[int]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make(map[int]Migration, len(entries))
+
+	for _, entry := range entries {
+		filePath := entry.Name()
+
+		migrationNumber, name, scriptType, matched, err := parseMigrationFilename(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		if !matched {
+			continue
+		}
+
 		m := migrations[migrationNumber]
 		m.Version = migrationNumber
-		m.Name = parts[1]
+		m.Name = name
 
 		// TODO: relative paths
-		migrationContent, err := os.ReadFile(path.Join(migrationsPath, filePath))
+		migrationContent, err := fs.ReadFile(fsys, path.Join(dir, filePath))
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		migrationScriptType := parts[2]
-		switch migrationScriptType {
+		switch scriptType {
 		case "up":
 			m.UpScript = string(migrationContent)
+			m.Checksum = checksum(m.UpScript)
 		case "down":
 			m.DownScript = string(migrationContent)
 		default:
-			return fmt.Errorf("uncrecognized script type: '%s'", migrationScriptType)
+			return nil, fmt.Errorf("uncrecognized script type: '%s'", scriptType)
 		}
 
 		migrations[migrationNumber] = m
 	}
 
+	for version, m := range migrations {
+		m.NoTransaction = hasDirective(m.UpScript, directiveNoTransaction) || hasDirective(m.DownScript, directiveNoTransaction)
+		migrations[version] = m
+	}
+
 	if err := validateFoundMigrationFiles(migrations); err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := ensureMigrationsSchema(ctx, db); err != nil {
-		return err
+	return mergeRegisteredMigrations(migrations)
+}
+
+// parseMigrationFilename parses a migration script filename, supporting
+// both the original sequential convention (migrationnumber.name.up.sql,
+// migrationnumber.name.down.sql) and a goose/sql-migrate-style timestamp
+// convention (20240115143022_name.up.sql), which avoids version
+// collisions between migrations authored on parallel branches. matched is
+// false, with no error, for filenames that don't match either convention
+// (e.g. a non-.sql file); a four-part name whose first part isn't an
+// integer is reported as an error instead.
+func parseMigrationFilename(filePath string) (version int, name, scriptType string, matched bool, err error) {
+	if filepath.Ext(filePath) != ".sql" {
+		return 0, "", "", false, nil
+	}
+
+	parts := strings.Split(filePath, ".")
+	switch len(parts) {
+	case 4:
+		// migrationnumber.name.up.sql / migrationnumber.name.down.sql
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, "", "", false, err
+		}
+		return version, parts[1], parts[2], true, nil
+	case 3:
+		// timestamp_name.up.sql / timestamp_name.down.sql
+		prefix, rest, found := strings.Cut(parts[0], "_")
+		if !found || len(prefix) != timestampPrefixLength {
+			return 0, "", "", false, nil
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return 0, "", "", false, nil
+		}
+
+		return version, rest, parts[1], true, nil
+	default:
+		// Doesn't match either naming convention.
+		return 0, "", "", false, nil
+	}
+}
+
+func checksum(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// pendingMigrations returns every migration not present in applied, i.e.
+// not yet recorded in 'schema_migration', sorted ascending by version. It
+// deliberately checks set membership rather than comparing against the
+// highest applied version: with timestamp-based filenames, a migration
+// merged from an older branch can sort below one that's already been
+// applied, and must still run.
+func pendingMigrations(migrations map[int]Migration, applied map[int]struct{}, upTo int) []Migration {
+	var result []Migration
+	for version, migration := range migrations {
+		if _, ok := applied[version]; ok {
+			continue
+		}
+
+		if upTo > 0 && version > upTo {
+			continue
+		}
+
+		result = append(result, migration)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result
+}
+
+// appliedVersionSet builds a set from appliedMigrationVersions' result, for
+// O(1) membership checks against it.
+func appliedVersionSet(appliedVersions []int) map[int]struct{} {
+	set := make(map[int]struct{}, len(appliedVersions))
+	for _, version := range appliedVersions {
+		set[version] = struct{}{}
+	}
+	return set
+}
+
+// verifyChecksums compares the checksum recorded for each already-applied
+// migration against the checksum of the matching file found on disk,
+// failing loudly if they differ. This catches migrations that were
+// edited after having been applied - a file that silently drifted from
+// what actually ran against the database.
+func verifyChecksums(ctx context.Context, db *sql.DB, dialect Dialect, migrations map[int]Migration) error {
+	type appliedChecksum struct {
+		Version  int    `db:"version"`
+		Checksum string `db:"checksum"`
 	}
 
-	const q = `
-		SELECT 
-		    version
-		FROM 
-		    schema_migration
-		ORDER BY 
-		    version DESC
-		LIMIT 1;`
-	lastAppliedMigrationVersion, err := tql.QueryFirstOrDefault[int](ctx, db, 0, q)
+	applied, err := tql.Query[appliedChecksum](ctx, db, dialect.AppliedChecksums())
 	if err != nil {
-		return fmt.Errorf("failed fetching last applied version: %w", err)
+		return fmt.Errorf("failed fetching applied migration checksums: %w", err)
 	}
 
-	var migrationsToApply []Migration
-	for migrationVersion, migration := range migrations {
-		if migrationVersion <= lastAppliedMigrationVersion {
+	var checksumErr error
+	for _, a := range applied {
+		// Migrations recorded before the checksum column existed have an
+		// empty checksum - nothing to compare them against.
+		if a.Checksum == "" {
 			continue
 		}
 
-		migrationsToApply = append(migrationsToApply, migration)
+		migration, ok := migrations[a.Version]
+		if !ok {
+			continue
+		}
+
+		if migration.Checksum != a.Checksum {
+			checksumErr = errors.Join(checksumErr, fmt.Errorf(
+				"migrate: migration '%s' (version %d) has changed since it was applied: checksum mismatch", migration.Name, migration.Version))
+		}
 	}
 
-	if len(migrationsToApply) == 0 {
-		return nil
+	return checksumErr
+}
+
+func validateFoundMigrationFiles(migrations map[int]Migration) error {
+	var missingScriptsErr error
+	for _, migration := range migrations {
+		if migration.DownScript == "" {
+			missingScriptsErr = errors.Join(missingScriptsErr, fmt.Errorf("failed to find 'down' script for '%s'", migration.Name))
+		}
+
+		if migration.UpScript == "" {
+			missingScriptsErr = errors.Join(missingScriptsErr, fmt.Errorf("failed to find 'up' script for '%s'", migration.Name))
+		}
 	}
+	return missingScriptsErr
+}
 
-	sort.Slice(migrationsToApply, func(i, j int) bool {
-		return migrationsToApply[i].Version < migrationsToApply[j].Version
-	})
+// applyMigrations runs each migration's up script in ascending order,
+// recording it in 'schema_migration'. If any migration fails, the
+// migrations already applied in this call are reverted. A migration
+// carrying a '-- +migrate NoTransaction' directive runs outside of a
+// transaction, for DDL that can't run inside one. o's BeforeEach, AfterEach
+// and OnError hooks, if set, fire around every migration, and progress is
+// logged through o.logger.
+func applyMigrations(ctx context.Context, db *sql.DB, o *options, migrations []Migration) error {
+	if len(migrations) == 0 {
+		return nil
+	}
 
 	var newlyAppliedMigrations []Migration
 
 	var migrationErr error
-	for _, migration := range migrationsToApply {
-		txFunc := func(ctx context.Context, tx *sql.Tx) error {
-			if _, err = tql.Exec(ctx, tx, migration.UpScript); err != nil {
-				return fmt.Errorf("failed running migration '%s' up script: %w", migration.Name, err)
-			}
+	for _, migration := range migrations {
+		if o.beforeEach != nil {
+			o.beforeEach(ctx, migration)
+		}
 
-			const stmt = `
-			INSERT INTO
-				schema_migration (version, name)
-			VALUES 
-			    ($1, $2);`
-			_, err = tql.Exec(ctx, tx, stmt, migration.Version, migration.Name)
-			if err != nil {
-				return fmt.Errorf("failed inserting migration '%s' into 'schema_migration': %w", migration.Name, err)
-			}
-			return nil
+		start := time.Now()
+		if migration.NoTransaction {
+			migrationErr = applyMigrationNoTx(ctx, db, o.dialect, migration)
+		} else {
+			migrationErr = applyMigrationTx(ctx, db, o.dialect, migration)
 		}
 
-		txOpts := sql.TxOptions{Isolation: sql.LevelSerializable}
-		if migrationErr = tx(ctx, db, &txOpts, txFunc); migrationErr != nil {
+		if migrationErr != nil {
+			o.logger.Printf("migrate: failed applying migration '%s': %s", migration.Name, migrationErr)
+			if o.onError != nil {
+				o.onError(ctx, migration, migrationErr)
+			}
 			break
 		}
 
+		duration := time.Since(start)
+		o.logger.Printf("migrate: applied migration '%s' in %s", migration.Name, duration)
+		if o.afterEach != nil {
+			o.afterEach(ctx, migration, duration)
+		}
+
 		newlyAppliedMigrations = append(newlyAppliedMigrations, migration)
 	}
 
 	if migrationErr != nil {
-		if err := revertState(ctx, db, newlyAppliedMigrations); err != nil {
+		if err := revertMigrations(ctx, db, o, newlyAppliedMigrations); err != nil {
 			return errors.Join(err, migrationErr)
 		}
 
@@ -162,71 +596,178 @@ func Run(ctx context.Context, db *sql.DB, migrationsPath string) error {
 	return nil
 }
 
-func validateFoundMigrationFiles(migrations map[int]Migration) error {
-	var missingScriptsErr error
-	for _, migration := range migrations {
-		if migration.DownScript == "" {
-			missingScriptsErr = errors.Join(missingScriptsErr, fmt.Errorf("failed to find 'down' script for '%s'", migration.Name))
+func applyMigrationTx(ctx context.Context, db *sql.DB, dialect Dialect, migration Migration) error {
+	txFunc := func(ctx context.Context, tx *sql.Tx) error {
+		start := time.Now()
+		if migration.UpFunc != nil {
+			if err := migration.UpFunc(ctx, tx); err != nil {
+				return fmt.Errorf("failed running migration '%s' up func: %w", migration.Name, err)
+			}
+		} else {
+			for _, stmt := range splitStatements(migration.UpScript) {
+				if _, err := tql.Exec(ctx, tx, stmt); err != nil {
+					return fmt.Errorf("failed running migration '%s' up script: %w", migration.Name, err)
+				}
+			}
 		}
+		executionTimeMs := time.Since(start).Milliseconds()
 
-		if migration.UpScript == "" {
-			missingScriptsErr = errors.Join(missingScriptsErr, fmt.Errorf("failed to find 'down' script for '%s'", migration.Name))
+		if _, err := tql.Exec(ctx, tx, dialect.InsertVersion(), migration.Version, migration.Name, migration.Checksum, executionTimeMs); err != nil {
+			return fmt.Errorf("failed inserting migration '%s' into 'schema_migration': %w", migration.Name, err)
 		}
+		return nil
 	}
-	return missingScriptsErr
+
+	txOpts := sql.TxOptions{Isolation: sql.LevelSerializable}
+	return tx(ctx, db, &txOpts, txFunc)
+}
+
+func applyMigrationNoTx(ctx context.Context, db *sql.DB, dialect Dialect, migration Migration) error {
+	start := time.Now()
+	for _, stmt := range splitStatements(migration.UpScript) {
+		if _, err := tql.Exec(ctx, db, stmt); err != nil {
+			return fmt.Errorf("failed running migration '%s' up script: %w", migration.Name, err)
+		}
+	}
+	executionTimeMs := time.Since(start).Milliseconds()
+
+	if _, err := tql.Exec(ctx, db, dialect.InsertVersion(), migration.Version, migration.Name, migration.Checksum, executionTimeMs); err != nil {
+		return fmt.Errorf("failed inserting migration '%s' into 'schema_migration': %w", migration.Name, err)
+	}
+	return nil
 }
 
-func revertState(ctx context.Context, db *sql.DB, appliedMigrations []Migration) error {
+// revertMigrations runs the down script for each of the given migrations,
+// in reverse order (highest version first), removing it from
+// 'schema_migration'. migrations is expected to be sorted ascending by
+// version.
+func revertMigrations(ctx context.Context, db *sql.DB, o *options, migrations []Migration) error {
 	var revertErr error
 
-	for i := len(appliedMigrations) - 1; i >= 0; i-- {
-		migration := appliedMigrations[i]
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
 
-		txFunc := func(ctx context.Context, tx *sql.Tx) error {
-			if _, err := tx.Exec(migration.DownScript); err != nil {
-				return err
-			}
+		if o.beforeEach != nil {
+			o.beforeEach(ctx, migration)
+		}
 
-			_, err := tx.Exec("DELETE FROM schema_migration WHERE version = $1", migration.Version)
-			return err
+		start := time.Now()
+		if migration.NoTransaction {
+			revertErr = revertMigrationNoTx(ctx, db, o.dialect, migration)
+		} else {
+			revertErr = revertMigrationTx(ctx, db, o.dialect, migration)
 		}
 
-		if revertErr = tx(ctx, db, nil, txFunc); revertErr != nil {
+		if revertErr != nil {
+			o.logger.Printf("migrate: failed reverting migration '%s': %s", migration.Name, revertErr)
+			if o.onError != nil {
+				o.onError(ctx, migration, revertErr)
+			}
 			break
 		}
+
+		duration := time.Since(start)
+		o.logger.Printf("migrate: reverted migration '%s' in %s", migration.Name, duration)
+		if o.afterEach != nil {
+			o.afterEach(ctx, migration, duration)
+		}
 	}
 
 	return revertErr
 }
 
-func ensureMigrationsSchema(ctx context.Context, db *sql.DB) error {
-	const checkIfSchemaExistsQuery = `
-		SELECT 
-		    count(table_name)
-		FROM 
-		    information_schema.tables
-		WHERE 
-		    table_name = $1;`
+func revertMigrationTx(ctx context.Context, db *sql.DB, dialect Dialect, migration Migration) error {
+	txFunc := func(ctx context.Context, tx *sql.Tx) error {
+		if migration.DownFunc != nil {
+			if err := migration.DownFunc(ctx, tx); err != nil {
+				return fmt.Errorf("failed running migration '%s' down func: %w", migration.Name, err)
+			}
+		} else {
+			for _, stmt := range splitStatements(migration.DownScript) {
+				if _, err := tql.Exec(ctx, tx, stmt); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err := tql.Exec(ctx, tx, dialect.DeleteVersion(), migration.Version)
+		return err
+	}
+
+	return tx(ctx, db, nil, txFunc)
+}
+
+func revertMigrationNoTx(ctx context.Context, db *sql.DB, dialect Dialect, migration Migration) error {
+	for _, stmt := range splitStatements(migration.DownScript) {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.ExecContext(ctx, dialect.DeleteVersion(), migration.Version)
+	return err
+}
 
-	schemas, err := tql.QueryFirst[int](ctx, db, checkIfSchemaExistsQuery, "schema_migration")
+func latestAppliedVersion(ctx context.Context, db *sql.DB, dialect Dialect) (int, error) {
+	version, err := tql.QueryFirstOrDefault[int](ctx, db, 0, dialect.LatestVersion())
+	if err != nil {
+		return 0, fmt.Errorf("failed fetching last applied version: %w", err)
+	}
+	return version, nil
+}
+
+func appliedMigrationVersions(ctx context.Context, db *sql.DB, dialect Dialect) ([]int, error) {
+	versions, err := tql.Query[int](ctx, db, dialect.AppliedVersions())
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching applied migration versions: %w", err)
+	}
+	return versions, nil
+}
+
+func ensureMigrationsSchema(ctx context.Context, db *sql.DB, dialect Dialect) error {
+	schemas, err := tql.QueryFirst[int](ctx, db, dialect.VersionTableExists())
 	if err != nil {
 		return fmt.Errorf("failed fetching if 'schema_migration' table exists: %w", err)
 	}
 
-	if schemas > 0 {
+	if schemas == 0 {
+		if _, err := tql.Exec(ctx, db, dialect.CreateVersionTable()); err != nil {
+			return fmt.Errorf("failed creating 'schema_migration' table: %w", err)
+		}
 		return nil
 	}
 
-	const stmt = `
-		CREATE TABLE schema_migration (
-			id serial PRIMARY KEY,
-			name text NOT NULL,
-			version integer NOT NULL
-		)`
+	return upgradeMigrationsSchema(ctx, db, dialect)
+}
 
-	_, err = tql.Exec(ctx, db, stmt)
+// requiredMigrationColumns are the 'schema_migration' columns a table
+// created by an older version of this library - before applied_at,
+// checksum and execution_time_ms existed - would be missing.
+var requiredMigrationColumns = []string{"applied_at", "checksum", "execution_time_ms"}
+
+// upgradeMigrationsSchema adds any column in requiredMigrationColumns
+// that's missing from an already-existing 'schema_migration' table, so
+// that a consumer upgrading straight to this version of the library
+// doesn't fail the first time InsertVersion runs against a stale schema.
+func upgradeMigrationsSchema(ctx context.Context, db *sql.DB, dialect Dialect) error {
+	existingColumns, err := tql.Query[string](ctx, db, dialect.ColumnNames())
 	if err != nil {
-		return fmt.Errorf("failed creating 'schema_migration' table: %w", err)
+		return fmt.Errorf("failed fetching 'schema_migration' columns: %w", err)
+	}
+
+	existing := make(map[string]struct{}, len(existingColumns))
+	for _, column := range existingColumns {
+		existing[strings.ToLower(column)] = struct{}{}
+	}
+
+	for _, column := range requiredMigrationColumns {
+		if _, ok := existing[column]; ok {
+			continue
+		}
+
+		if _, err := tql.Exec(ctx, db, dialect.AddColumn(column)); err != nil {
+			return fmt.Errorf("failed adding '%s' column to 'schema_migration': %w", column, err)
+		}
 	}
 
 	return nil
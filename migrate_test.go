@@ -0,0 +1,172 @@
+package migrate
+
+import "testing"
+
+func TestParseMigrationFilename(t *testing.T) {
+	tests := []struct {
+		name           string
+		filePath       string
+		wantVersion    int
+		wantName       string
+		wantScriptType string
+		wantMatched    bool
+		wantErr        bool
+	}{
+		{
+			name:           "integer version up script",
+			filePath:       "1.create_users.up.sql",
+			wantVersion:    1,
+			wantName:       "create_users",
+			wantScriptType: "up",
+			wantMatched:    true,
+		},
+		{
+			name:           "integer version down script",
+			filePath:       "42.create_users.down.sql",
+			wantVersion:    42,
+			wantName:       "create_users",
+			wantScriptType: "down",
+			wantMatched:    true,
+		},
+		{
+			name:           "timestamp version up script",
+			filePath:       "20240115143022_create_users.up.sql",
+			wantVersion:    20240115143022,
+			wantName:       "create_users",
+			wantScriptType: "up",
+			wantMatched:    true,
+		},
+		{
+			name:           "timestamp version down script",
+			filePath:       "20240115143022_create_users.down.sql",
+			wantVersion:    20240115143022,
+			wantName:       "create_users",
+			wantScriptType: "down",
+			wantMatched:    true,
+		},
+		{
+			name:        "non-sql file",
+			filePath:    "README.md",
+			wantMatched: false,
+		},
+		{
+			name:        "three parts without a timestamp prefix",
+			filePath:    "create_users.up.sql",
+			wantMatched: false,
+		},
+		{
+			name:        "timestamp prefix too short",
+			filePath:    "202401_create_users.up.sql",
+			wantMatched: false,
+		},
+		{
+			name:        "non-numeric integer version",
+			filePath:    "abc.create_users.up.sql",
+			wantMatched: false,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, name, scriptType, matched, err := parseMigrationFilename(tt.filePath)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMigrationFilename(%q) error = %v, wantErr %v", tt.filePath, err, tt.wantErr)
+			}
+
+			if matched != tt.wantMatched {
+				t.Fatalf("parseMigrationFilename(%q) matched = %v, want %v", tt.filePath, matched, tt.wantMatched)
+			}
+
+			if !tt.wantMatched {
+				return
+			}
+
+			if version != tt.wantVersion {
+				t.Errorf("parseMigrationFilename(%q) version = %d, want %d", tt.filePath, version, tt.wantVersion)
+			}
+			if name != tt.wantName {
+				t.Errorf("parseMigrationFilename(%q) name = %q, want %q", tt.filePath, name, tt.wantName)
+			}
+			if scriptType != tt.wantScriptType {
+				t.Errorf("parseMigrationFilename(%q) scriptType = %q, want %q", tt.filePath, scriptType, tt.wantScriptType)
+			}
+		})
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	if got := checksum("same script"); got != checksum("same script") {
+		t.Errorf("checksum is not deterministic: got %q and %q for identical input", got, checksum("same script"))
+	}
+
+	if checksum("script a") == checksum("script b") {
+		t.Error("checksum returned the same value for different scripts")
+	}
+
+	if got := checksum(""); len(got) != 64 {
+		t.Errorf("checksum(%q) = %q, want a 64-character hex string", "", got)
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	migrations := map[int]Migration{
+		1: {Version: 1, Name: "a"},
+		2: {Version: 2, Name: "b"},
+		3: {Version: 3, Name: "c"},
+	}
+
+	t.Run("excludes applied versions regardless of their magnitude", func(t *testing.T) {
+		// Version 3 is applied but version 2 is not - a timestamp-prefixed
+		// migration merged from an older branch after a newer one already
+		// ran must still be treated as pending.
+		applied := map[int]struct{}{3: {}}
+
+		got := pendingMigrations(migrations, applied, 0)
+
+		if len(got) != 2 || got[0].Version != 1 || got[1].Version != 2 {
+			t.Fatalf("pendingMigrations = %+v, want versions [1, 2]", got)
+		}
+	})
+
+	t.Run("upTo caps the returned versions", func(t *testing.T) {
+		got := pendingMigrations(migrations, map[int]struct{}{}, 2)
+
+		if len(got) != 2 || got[0].Version != 1 || got[1].Version != 2 {
+			t.Fatalf("pendingMigrations = %+v, want versions [1, 2]", got)
+		}
+	})
+
+	t.Run("result is sorted ascending by version", func(t *testing.T) {
+		got := pendingMigrations(migrations, map[int]struct{}{}, 0)
+
+		for i := 1; i < len(got); i++ {
+			if got[i-1].Version > got[i].Version {
+				t.Fatalf("pendingMigrations is not sorted ascending: %+v", got)
+			}
+		}
+	})
+
+	t.Run("nothing pending", func(t *testing.T) {
+		applied := map[int]struct{}{1: {}, 2: {}, 3: {}}
+
+		if got := pendingMigrations(migrations, applied, 0); len(got) != 0 {
+			t.Fatalf("pendingMigrations = %+v, want none", got)
+		}
+	})
+}
+
+func TestAppliedVersionSet(t *testing.T) {
+	set := appliedVersionSet([]int{1, 3, 5})
+
+	for _, version := range []int{1, 3, 5} {
+		if _, ok := set[version]; !ok {
+			t.Errorf("appliedVersionSet missing version %d", version)
+		}
+	}
+
+	if _, ok := set[2]; ok {
+		t.Error("appliedVersionSet contains version 2, which was never applied")
+	}
+}
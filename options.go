@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type options struct {
+	dialect     Dialect
+	lockTimeout time.Duration
+	logger      Logger
+	beforeEach  func(ctx context.Context, migration Migration)
+	afterEach   func(ctx context.Context, migration Migration, duration time.Duration)
+	onError     func(ctx context.Context, migration Migration, err error)
+}
+
+// Option configures optional, cross-cutting behavior for the migration
+// runner functions (Up, Down, Status, ...).
+type Option func(*options)
+
+// Logger receives a line of structured-ish output for every migration the
+// runner functions apply or revert. It matches the subset of *log.Logger
+// callers reach for most often, so the standard library logger, and most
+// structured logging wrappers, satisfy it without an adapter.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Printf(string, ...any) {}
+
+// WithLogger routes migrate's own progress output (which migration is
+// running, how long it took, any failure) through logger instead of
+// discarding it. The default is a no-op logger.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithBeforeEach registers a hook called immediately before a migration
+// runs, up or down. Use it to emit a metric or tracing span per migration.
+func WithBeforeEach(f func(ctx context.Context, migration Migration)) Option {
+	return func(o *options) {
+		o.beforeEach = f
+	}
+}
+
+// WithAfterEach registers a hook called after a migration completes
+// successfully, with the time it took to run. Use it to record a
+// histogram or duration metric per migration.
+func WithAfterEach(f func(ctx context.Context, migration Migration, duration time.Duration)) Option {
+	return func(o *options) {
+		o.afterEach = f
+	}
+}
+
+// WithOnError registers a hook called when a migration fails to apply or
+// revert, before the error is returned to the caller.
+func WithOnError(f func(ctx context.Context, migration Migration, err error)) Option {
+	return func(o *options) {
+		o.onError = f
+	}
+}
+
+// WithDialect overrides dialect auto-detection, forcing migrate to use d for
+// all 'schema_migration' SQL. Use this when the driver name can't be
+// sniffed reliably, or to force a dialect other than the one detectDialect
+// would pick.
+func WithDialect(d Dialect) Option {
+	return func(o *options) {
+		o.dialect = d
+	}
+}
+
+// WithLockTimeout bounds how long a runner function waits to acquire the
+// cross-process migration lock (see lock) before giving up. The zero value
+// waits indefinitely, which is the default.
+func WithLockTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.lockTimeout = d
+	}
+}
+
+func resolveOptions(db *sql.DB, opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.dialect == nil {
+		o.dialect = detectDialect(db)
+	}
+
+	if o.logger == nil {
+		o.logger = noopLogger{}
+	}
+
+	return o
+}
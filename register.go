@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// registeredMigrations holds Go migrations added via Register, keyed by
+// version, merged with on-disk SQL migrations by mergeRegisteredMigrations
+// whenever migrationsPath is loaded.
+var registeredMigrations = map[int]Migration{}
+
+// Register adds a programmatic Go migration identified by version, for
+// application logic that static SQL can't cleanly express - data
+// backfills, JSON reshaping, calls into application code. It is merged
+// with the on-disk SQL migrations the next time a runner function (Up,
+// Down, Status, ...) loads migrationsPath, and runs through the same
+// transactional/locking machinery.
+//
+// Register is meant to be called from an init() function at program
+// startup; a version collision at that point is a programming error, so
+// Register panics rather than returning one.
+func Register(version int, name string, up, down func(context.Context, *sql.Tx) error) {
+	if _, exists := registeredMigrations[version]; exists {
+		panic(fmt.Sprintf("migrate: Go migration version %d already registered", version))
+	}
+
+	registeredMigrations[version] = Migration{
+		Version:  version,
+		Name:     name,
+		UpFunc:   up,
+		DownFunc: down,
+	}
+}
+
+// mergeRegisteredMigrations merges sqlMigrations with every migration
+// added via Register, erroring if a registered version collides with one
+// found on disk.
+func mergeRegisteredMigrations(sqlMigrations map[int]Migration) (map[int]Migration, error) {
+	if len(registeredMigrations) == 0 {
+		return sqlMigrations, nil
+	}
+
+	merged := make(map[int]Migration, len(sqlMigrations)+len(registeredMigrations))
+	for version, m := range sqlMigrations {
+		merged[version] = m
+	}
+
+	for version, m := range registeredMigrations {
+		if _, exists := merged[version]; exists {
+			return nil, fmt.Errorf("migrate: registered Go migration version %d collides with a migration found on disk", version)
+		}
+		merged[version] = m
+	}
+
+	return merged, nil
+}
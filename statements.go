@@ -0,0 +1,65 @@
+package migrate
+
+import "strings"
+
+// Directives recognized in a migration script, written as a standalone
+// SQL comment line in the spirit of goose's "-- +goose ..." annotations.
+const (
+	directivePrefix         = "-- +migrate "
+	directiveNoTransaction  = "NoTransaction"
+	directiveStatementBegin = "StatementBegin"
+	directiveStatementEnd   = "StatementEnd"
+)
+
+func hasDirective(script, directive string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		if strings.TrimSpace(line) == directivePrefix+directive {
+			return true
+		}
+	}
+	return false
+}
+
+// splitStatements splits script into individually-executable statements.
+// A '-- +migrate StatementBegin' / '-- +migrate StatementEnd' pair groups
+// everything between them - typically a stored procedure or DO block
+// containing its own semicolons - into a single statement that would
+// otherwise be torn apart by the naive semicolon-per-line splitting used
+// everywhere else.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	inStatementBlock := false
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		switch strings.TrimSpace(line) {
+		case directivePrefix + directiveNoTransaction:
+			continue
+		case directivePrefix + directiveStatementBegin:
+			inStatementBlock = true
+			continue
+		case directivePrefix + directiveStatementEnd:
+			inStatementBlock = false
+			flush()
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if !inStatementBlock && strings.HasSuffix(strings.TrimSpace(line), ";") {
+			flush()
+		}
+	}
+
+	flush()
+
+	return statements
+}
@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHasDirective(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		directive string
+		want      bool
+	}{
+		{
+			name:      "directive present on its own line",
+			script:    "-- +migrate NoTransaction\nCREATE INDEX CONCURRENTLY foo ON bar (baz);",
+			directive: directiveNoTransaction,
+			want:      true,
+		},
+		{
+			name:      "directive absent",
+			script:    "CREATE TABLE foo (id int);",
+			directive: directiveNoTransaction,
+			want:      false,
+		},
+		{
+			name:      "directive indented is still matched after trimming",
+			script:    "  -- +migrate NoTransaction  \nSELECT 1;",
+			directive: directiveNoTransaction,
+			want:      true,
+		},
+		{
+			name:      "different directive does not match",
+			script:    "-- +migrate StatementBegin\nSELECT 1;\n-- +migrate StatementEnd",
+			directive: directiveNoTransaction,
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasDirective(tt.script, tt.directive); got != tt.want {
+				t.Errorf("hasDirective(%q, %q) = %v, want %v", tt.script, tt.directive, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "single statement",
+			script: "SELECT 1;",
+			want:   []string{"SELECT 1;"},
+		},
+		{
+			name:   "multiple statements on separate lines",
+			script: "CREATE TABLE foo (id int);\nCREATE TABLE bar (id int);",
+			want:   []string{"CREATE TABLE foo (id int);", "CREATE TABLE bar (id int);"},
+		},
+		{
+			name:   "NoTransaction directive is stripped",
+			script: "-- +migrate NoTransaction\nCREATE INDEX CONCURRENTLY foo ON bar (baz);",
+			want:   []string{"CREATE INDEX CONCURRENTLY foo ON bar (baz);"},
+		},
+		{
+			name: "StatementBegin/End groups a multi-statement body into one statement",
+			script: "-- +migrate StatementBegin\n" +
+				"CREATE FUNCTION foo() RETURNS void AS $$\n" +
+				"BEGIN\n" +
+				"  PERFORM 1;\n" +
+				"END;\n" +
+				"$$ LANGUAGE plpgsql;\n" +
+				"-- +migrate StatementEnd\n" +
+				"SELECT 1;",
+			want: []string{
+				"CREATE FUNCTION foo() RETURNS void AS $$\nBEGIN\n  PERFORM 1;\nEND;\n$$ LANGUAGE plpgsql;",
+				"SELECT 1;",
+			},
+		},
+		{
+			name:   "empty script yields no statements",
+			script: "",
+			want:   nil,
+		},
+		{
+			name:   "trailing whitespace-only line is dropped",
+			script: "SELECT 1;\n   \n",
+			want:   []string{"SELECT 1;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %#v, want %#v", tt.script, got, tt.want)
+			}
+		})
+	}
+}